@@ -0,0 +1,263 @@
+/*
+ * TWITTER-BACKUP
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/coreos/pkg/flagutil"
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/dghubble/oauth1"
+)
+
+// credentialFlags registers the Twitter app credential flags shared by
+// every subcommand that talks to the API, plus --full and --accounts.
+func credentialFlags(flags *flag.FlagSet) (consumerKey, consumerSecret, accessToken, accessSecret *string, full *bool, accounts *string) {
+	consumerKey = flags.String("consumer-key", "", "Twitter Consumer Key")
+	consumerSecret = flags.String("consumer-secret", "", "Twitter Consumer Secret")
+	accessToken = flags.String("access-token", "", "Twitter Access Token")
+	accessSecret = flags.String("access-secret", "", "Twitter Access Secret")
+	full = flags.Bool("full", false, "force a from-scratch walk, ignoring any saved state")
+	accounts = flags.String("accounts", "", "path to a JSON config file listing multiple accounts to back up concurrently, instead of a single one")
+	return
+}
+
+// newClient parses args with flags, falls back to TWITTER_* environment
+// variables, and returns an authenticated, verified twitter.Client along
+// with the verified account's User.
+func newClient(flags *flag.FlagSet, args []string, consumerKey, consumerSecret, accessToken, accessSecret *string) (*twitter.Client, *twitter.User) {
+	err := flags.Parse(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = flagutil.SetFlagsFromEnv(flags, "TWITTER")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *consumerKey == "" ||
+		*consumerSecret == "" ||
+		*accessToken == "" ||
+		*accessSecret == "" {
+		log.Fatal(
+			"Consumer key/secret and Access token/secret required. Got",
+			" consumerKey: ", *consumerKey != "",
+			" consumerSecret: ", *consumerSecret != "",
+			" accessToken: ", *accessToken != "",
+			" accessSecret: ", *accessSecret != "",
+		)
+	}
+
+	config := oauth1.NewConfig(*consumerKey, *consumerSecret)
+	token := oauth1.NewToken(*accessToken, *accessSecret)
+	httpClient := config.Client(oauth1.NoContext, token)
+	client := twitter.NewClient(httpClient)
+
+	user, _, errVerify := client.Accounts.VerifyCredentials(&twitter.AccountVerifyParams{
+		SkipStatus:   twitter.Bool(true),
+		IncludeEmail: twitter.Bool(true),
+	})
+	if errVerify != nil {
+		log.Fatal("failed to verify credentials", errVerify)
+	}
+
+	return client, user
+}
+
+// cmdLikes backs up the authenticated user's liked tweets.
+func cmdLikes(args []string) {
+	flags := flag.NewFlagSet("likes", flag.ExitOnError)
+	consumerKey, consumerSecret, accessToken, accessSecret, full, accounts := credentialFlags(flags)
+
+	if err := flags.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+
+	if *accounts != "" {
+		runForEachAccount(*accounts, func(client *twitter.Client, _ *twitter.User, outputDir string) string {
+			total := backupTimeline(&favoritesSource{client: client}, filepath.Join(outputDir, pathLikes), *full)
+			return fmt.Sprintf("likes: %d", total)
+		})
+		return
+	}
+
+	client, _ := newClient(flags, args, consumerKey, consumerSecret, accessToken, accessSecret)
+
+	fmt.Println("downloading likes")
+	total := backupTimeline(&favoritesSource{client: client}, pathLikes, *full)
+	fmt.Println("\nsummary: likes:", total)
+}
+
+// cmdHome backs up the authenticated user's home timeline.
+func cmdHome(args []string) {
+	flags := flag.NewFlagSet("home", flag.ExitOnError)
+	consumerKey, consumerSecret, accessToken, accessSecret, full, accounts := credentialFlags(flags)
+
+	if err := flags.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+
+	if *accounts != "" {
+		runForEachAccount(*accounts, func(client *twitter.Client, _ *twitter.User, outputDir string) string {
+			total := backupTimeline(&homeTimelineSource{client: client}, filepath.Join(outputDir, "home"), *full)
+			return fmt.Sprintf("home: %d", total)
+		})
+		return
+	}
+
+	client, _ := newClient(flags, args, consumerKey, consumerSecret, accessToken, accessSecret)
+
+	fmt.Println("downloading home timeline")
+	total := backupTimeline(&homeTimelineSource{client: client}, "home", *full)
+	fmt.Println("\nsummary: home:", total)
+}
+
+// cmdUser backs up the tweets posted by --screen-name, defaulting to the
+// authenticated user's own account.
+func cmdUser(args []string) {
+	flags := flag.NewFlagSet("user", flag.ExitOnError)
+	consumerKey, consumerSecret, accessToken, accessSecret, full, accounts := credentialFlags(flags)
+	screenName := flags.String("screen-name", "", "screen name of the account to back up, defaults to the authenticated user")
+
+	if err := flags.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+
+	if *accounts != "" {
+		runForEachAccount(*accounts, func(client *twitter.Client, verified *twitter.User, outputDir string) string {
+			name := *screenName
+			if name == "" {
+				name = verified.ScreenName
+			}
+			total := backupTimeline(&userTimelineSource{client: client, screenName: name}, filepath.Join(outputDir, "user-"+name), *full)
+			return fmt.Sprintf("user: %d", total)
+		})
+		return
+	}
+
+	client, verified := newClient(flags, args, consumerKey, consumerSecret, accessToken, accessSecret)
+
+	name := *screenName
+	if name == "" {
+		name = verified.ScreenName
+	}
+
+	fmt.Println("downloading tweets for", name)
+	total := backupTimeline(&userTimelineSource{client: client, screenName: name}, "user-"+name, *full)
+	fmt.Println("\nsummary: user:", total)
+}
+
+// cmdMentions backs up tweets mentioning the authenticated user.
+func cmdMentions(args []string) {
+	flags := flag.NewFlagSet("mentions", flag.ExitOnError)
+	consumerKey, consumerSecret, accessToken, accessSecret, full, accounts := credentialFlags(flags)
+
+	if err := flags.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+
+	if *accounts != "" {
+		runForEachAccount(*accounts, func(client *twitter.Client, _ *twitter.User, outputDir string) string {
+			total := backupTimeline(&mentionTimelineSource{client: client}, filepath.Join(outputDir, "mentions"), *full)
+			return fmt.Sprintf("mentions: %d", total)
+		})
+		return
+	}
+
+	client, _ := newClient(flags, args, consumerKey, consumerSecret, accessToken, accessSecret)
+
+	fmt.Println("downloading mentions")
+	total := backupTimeline(&mentionTimelineSource{client: client}, "mentions", *full)
+	fmt.Println("\nsummary: mentions:", total)
+}
+
+// cmdFollowing backs up the accounts the authenticated user follows.
+func cmdFollowing(args []string) {
+	flags := flag.NewFlagSet("following", flag.ExitOnError)
+	consumerKey, consumerSecret, accessToken, accessSecret, full, accounts := credentialFlags(flags)
+
+	if err := flags.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+
+	if *accounts != "" {
+		runForEachAccount(*accounts, func(client *twitter.Client, _ *twitter.User, outputDir string) string {
+			total := downloadFollowedUsers(client, filepath.Join(outputDir, pathFollowing), *full)
+			return fmt.Sprintf("following: %d", total)
+		})
+		return
+	}
+
+	client, _ := newClient(flags, args, consumerKey, consumerSecret, accessToken, accessSecret)
+
+	fmt.Println("downloading followed users")
+	total := downloadFollowedUsers(client, pathFollowing, *full)
+	fmt.Println("\nsummary: following:", total)
+}
+
+// cmdAll runs every source in sequence, honoring each one's state file.
+func cmdAll(args []string) {
+	flags := flag.NewFlagSet("all", flag.ExitOnError)
+	consumerKey, consumerSecret, accessToken, accessSecret, full, accounts := credentialFlags(flags)
+	screenName := flags.String("screen-name", "", "screen name of the account to back up, defaults to the authenticated user")
+
+	if err := flags.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+
+	if *accounts != "" {
+		runForEachAccount(*accounts, func(client *twitter.Client, verified *twitter.User, outputDir string) string {
+			name := *screenName
+			if name == "" {
+				name = verified.ScreenName
+			}
+
+			likes := backupTimeline(&favoritesSource{client: client}, filepath.Join(outputDir, pathLikes), *full)
+			home := backupTimeline(&homeTimelineSource{client: client}, filepath.Join(outputDir, "home"), *full)
+			user := backupTimeline(&userTimelineSource{client: client, screenName: name}, filepath.Join(outputDir, "user-"+name), *full)
+			mentions := backupTimeline(&mentionTimelineSource{client: client}, filepath.Join(outputDir, "mentions"), *full)
+			following := downloadFollowedUsers(client, filepath.Join(outputDir, pathFollowing), *full)
+
+			return fmt.Sprintf("likes: %d home: %d user: %d mentions: %d following: %d", likes, home, user, mentions, following)
+		})
+		return
+	}
+
+	client, verified := newClient(flags, args, consumerKey, consumerSecret, accessToken, accessSecret)
+
+	name := *screenName
+	if name == "" {
+		name = verified.ScreenName
+	}
+
+	fmt.Println("downloading likes")
+	likes := backupTimeline(&favoritesSource{client: client}, pathLikes, *full)
+
+	fmt.Println("downloading home timeline")
+	home := backupTimeline(&homeTimelineSource{client: client}, "home", *full)
+
+	fmt.Println("downloading tweets for", name)
+	user := backupTimeline(&userTimelineSource{client: client, screenName: name}, "user-"+name, *full)
+
+	fmt.Println("downloading mentions")
+	mentions := backupTimeline(&mentionTimelineSource{client: client}, "mentions", *full)
+
+	fmt.Println("downloading followed users")
+	following := downloadFollowedUsers(client, pathFollowing, *full)
+
+	fmt.Println("\nsummary: likes:", likes, "home:", home, "user:", user, "mentions:", mentions, "following:", following)
+}
@@ -0,0 +1,163 @@
+/*
+ * TWITTER-BACKUP
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dghubble/oauth1"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// account describes the credentials and output location for a single
+// Twitter account to back up, as listed in a multi-account config file.
+type account struct {
+	Title             string `json:"title"`
+	ConsumerKey       string `json:"consumer_key"`
+	ConsumerSecret    string `json:"consumer_secret"`
+	AccessTokenKey    string `json:"access_token_key"`
+	AccessTokenSecret string `json:"access_token_secret"`
+	OutputDir         string `json:"output_dir"`
+}
+
+// loadAccounts reads a JSON config file describing multiple accounts to
+// back up concurrently.
+func loadAccounts(path string) ([]account, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []account
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return nil, err
+	}
+
+	if err := validateAccountOutputDirs(accounts); err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+// validateAccountOutputDirs ensures every account has a non-empty, unique
+// OutputDir, since concurrent workers writing into the same directory would
+// race on the same state.json and <id>.json files with no locking.
+func validateAccountOutputDirs(accounts []account) error {
+	seen := make(map[string]string, len(accounts))
+	for _, acc := range accounts {
+		if acc.OutputDir == "" {
+			return fmt.Errorf("account %q has no output_dir configured", acc.Title)
+		}
+		if other, ok := seen[acc.OutputDir]; ok {
+			return fmt.Errorf("accounts %q and %q share the same output_dir %q", other, acc.Title, acc.OutputDir)
+		}
+		seen[acc.OutputDir] = acc.Title
+	}
+	return nil
+}
+
+// accountResult is the per-account result printed once every worker
+// goroutine in runForEachAccount has finished.
+type accountResult struct {
+	Title    string
+	Summary  string
+	Duration time.Duration
+	Err      error
+}
+
+// runForEachAccount builds one twitter.Client per account configured in
+// configPath and runs task for each of them concurrently, one goroutine per
+// account, so that a single slow or rate-limited account doesn't hold up
+// the others. task receives the verified account (for resolving things like
+// a default screen name) and the account's own output directory, and
+// returns the summary line to print for it.
+func runForEachAccount(configPath string, task func(client *twitter.Client, verified *twitter.User, outputDir string) string) {
+	accounts, err := loadAccounts(configPath)
+	if err != nil {
+		log.Fatal("failed to load accounts config: ", err)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = make([]accountResult, 0, len(accounts))
+	)
+
+	for _, acc := range accounts {
+		acc := acc
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+
+			config := oauth1.NewConfig(acc.ConsumerKey, acc.ConsumerSecret)
+			token := oauth1.NewToken(acc.AccessTokenKey, acc.AccessTokenSecret)
+			httpClient := config.Client(oauth1.NoContext, token)
+			client := twitter.NewClient(httpClient)
+
+			verified, _, errVerify := client.Accounts.VerifyCredentials(&twitter.AccountVerifyParams{
+				SkipStatus:   twitter.Bool(true),
+				IncludeEmail: twitter.Bool(true),
+			})
+			if errVerify != nil {
+				mu.Lock()
+				results = append(results, accountResult{Title: acc.Title, Err: errVerify})
+				mu.Unlock()
+				return
+			}
+
+			fmt.Println("[" + acc.Title + "] starting backup")
+			summary := task(client, verified, acc.OutputDir)
+
+			mu.Lock()
+			results = append(results, accountResult{
+				Title:    acc.Title,
+				Summary:  summary,
+				Duration: time.Since(start),
+			})
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	fmt.Println("\nsummary:")
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Println("-", r.Title, "failed:", r.Err)
+			continue
+		}
+		fmt.Println("-", r.Title, r.Summary, "took", r.Duration)
+	}
+}
+
+// runMultiAccount runs the likes/following backup for every account
+// configured in configPath concurrently.
+func runMultiAccount(configPath string, full bool) {
+	runForEachAccount(configPath, func(client *twitter.Client, _ *twitter.User, outputDir string) string {
+		likes := downloadLikedTweets(client, filepath.Join(outputDir, pathLikes), full)
+		following := downloadFollowedUsers(client, filepath.Join(outputDir, pathFollowing), full)
+		return fmt.Sprintf("likes: %d following: %d", likes, following)
+	})
+}
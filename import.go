@@ -0,0 +1,401 @@
+/*
+ * TWITTER-BACKUP
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+const (
+	pathTweets = "tweets"
+	pathDMs    = "dms"
+)
+
+// jsPrefix matches the "window.YTD.<name>.part<n> = " wrapper that Twitter
+// puts in front of every JSON file shipped in the account data export, so
+// the file can be loaded directly via a <script> tag.
+var jsPrefix = regexp.MustCompile(`^\s*window\.YTD\.\w+\.part\d+\s*=\s*`)
+
+// archiveLike mirrors a single entry of data/like.js in a Twitter account archive.
+type archiveLike struct {
+	Like struct {
+		TweetID     string `json:"tweetId"`
+		FullText    string `json:"fullText"`
+		ExpandedURL string `json:"expandedUrl"`
+	} `json:"like"`
+}
+
+// archiveTweet mirrors a single entry of data/tweet.js in a Twitter account archive.
+type archiveTweet struct {
+	Tweet twitter.Tweet `json:"tweet"`
+}
+
+// archiveFollowing mirrors a single entry of data/following.js in a Twitter account archive.
+type archiveFollowing struct {
+	Following struct {
+		AccountID string `json:"accountId"`
+		UserLink  string `json:"userLink"`
+	} `json:"following"`
+}
+
+// archiveDirectMessage mirrors a single entry of data/direct-messages.js in a Twitter account archive.
+type archiveDirectMessage struct {
+	DMConversation struct {
+		ConversationID string          `json:"conversationId"`
+		Messages       json.RawMessage `json:"messages"`
+	} `json:"dmConversation"`
+}
+
+// cmdImport ingests the ZIP archive a user downloads from Twitter's "Your
+// Archive" feature and reconciles it into the same on-disk layout produced
+// by downloadLikedTweets / downloadFollowedUsers, so that a partial
+// API-based backup can be topped up without re-downloading anything.
+func cmdImport(args []string) {
+
+	var (
+		flags   = flag.NewFlagSet("import", flag.ExitOnError)
+		archive = flags.String("archive", "", "path to the Twitter account archive ZIP file")
+	)
+
+	err := flags.Parse(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *archive == "" {
+		log.Fatal("-archive is required")
+	}
+
+	r, err := zip.OpenReader(*archive)
+	if err != nil {
+		log.Fatal("failed to open archive: ", err)
+	}
+	defer r.Close()
+
+	files := make(map[string]*zip.File)
+	var mediaFiles []*zip.File
+
+	for _, f := range r.File {
+		switch {
+		case strings.HasPrefix(f.Name, "data/tweets_media/"):
+			mediaFiles = append(mediaFiles, f)
+		default:
+			files[f.Name] = f
+		}
+	}
+
+	if f, ok := files["data/like.js"]; ok {
+		importLikes(f)
+	}
+
+	if f, ok := files["data/tweet.js"]; ok {
+		importTweets(f, mediaFiles)
+	}
+
+	if f, ok := files["data/following.js"]; ok {
+		importFollowing(f)
+	}
+
+	if f, ok := files["data/direct-messages.js"]; ok {
+		importDirectMessages(f)
+	}
+}
+
+// readArchiveJSON opens a JS-wrapped JSON file from the archive and
+// unmarshals it into v after stripping the "window.YTD..." prefix.
+func readArchiveJSON(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+
+	data = jsPrefix.ReplaceAll(data, nil)
+
+	return json.Unmarshal(data, v)
+}
+
+// likeToTweet synthesizes a twitter.Tweet from an archive like entry, using
+// the same field names (id_str, full_text, …) as the tweets API returns, so
+// that files written here are indistinguishable from API-sourced ones to
+// scanTweetDir and anything else that reads likes/*.json.
+func likeToTweet(l archiveLike) twitter.Tweet {
+	id, _ := strconv.ParseInt(l.Like.TweetID, 10, 64)
+	return twitter.Tweet{
+		ID:       id,
+		IDStr:    l.Like.TweetID,
+		FullText: l.Like.FullText,
+	}
+}
+
+func importLikes(f *zip.File) {
+	var entries []archiveLike
+
+	err := readArchiveJSON(f, &entries)
+	if err != nil {
+		log.Fatal("failed to parse ", f.Name, ": ", err)
+	}
+
+	_ = os.Mkdir(pathLikes, pathPermission)
+
+	var imported int
+	for _, e := range entries {
+		filename := filepath.Join(pathLikes, e.Like.TweetID+".json")
+		if _, err := os.Stat(filename); err == nil {
+			continue
+		}
+
+		data, err := json.MarshalIndent(likeToTweet(e), " ", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = os.WriteFile(filename, data, filePermission)
+		if err != nil {
+			log.Fatal("failed to write file: ", filename, " error: ", err)
+		}
+		imported++
+	}
+
+	fmt.Println("imported", imported, "likes from archive")
+}
+
+func importTweets(f *zip.File, mediaFiles []*zip.File) {
+	var entries []archiveTweet
+
+	err := readArchiveJSON(f, &entries)
+	if err != nil {
+		log.Fatal("failed to parse ", f.Name, ": ", err)
+	}
+
+	_ = os.Mkdir(pathTweets, pathPermission)
+
+	var imported, numAssets int
+	for _, e := range entries {
+		t := e.Tweet
+
+		filename := filepath.Join(pathTweets, t.IDStr+".json")
+		if _, err := os.Stat(filename); err != nil {
+			data, err := json.MarshalIndent(t, " ", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			err = os.WriteFile(filename, data, filePermission)
+			if err != nil {
+				log.Fatal("failed to write file: ", filename, " error: ", err)
+			}
+			imported++
+		}
+
+		numAssets += importTweetMedia(t, mediaFiles)
+	}
+
+	fmt.Println("imported", imported, "tweets and", numAssets, "media assets from archive")
+}
+
+// importTweetMedia downloads media referenced in a tweet's entities and
+// copies any matching media shipped inside the archive's
+// data/tweets_media/ directory into tweets/<id>-media/.
+func importTweetMedia(t twitter.Tweet, mediaFiles []*zip.File) int {
+
+	var numAssets int
+
+	mediaDir := filepath.Join(pathTweets, t.IDStr+"-media")
+
+	if t.Entities != nil && len(t.Entities.Media) > 0 && t.ExtendedEntities != nil {
+		if _, err := os.Stat(mediaDir); err != nil {
+			err = os.Mkdir(mediaDir, pathPermission)
+			if err != nil {
+				log.Fatal("failed to create media directory: ", err)
+			}
+		}
+
+		for _, m := range t.ExtendedEntities.Media {
+			dest := filepath.Join(mediaDir, filepath.Base(m.MediaURL))
+			if _, err := os.Stat(dest); err == nil {
+				continue
+			}
+
+			resp, err := http.Get(m.MediaURL)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+
+			if resp.StatusCode == http.StatusOK {
+				data, err := ioutil.ReadAll(resp.Body)
+				if err != nil {
+					log.Fatal(err)
+				}
+				_ = resp.Body.Close()
+
+				err = os.WriteFile(dest, data, filePermission)
+				if err != nil {
+					log.Fatal(err)
+				}
+				numAssets++
+			} else {
+				_ = resp.Body.Close()
+				fmt.Println(resp.Status, "skipping", m.MediaURL)
+			}
+		}
+	}
+
+	prefix := t.IDStr + "-"
+	for _, mf := range mediaFiles {
+		name := filepath.Base(mf.Name)
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		if _, err := os.Stat(mediaDir); err != nil {
+			err = os.Mkdir(mediaDir, pathPermission)
+			if err != nil {
+				log.Fatal("failed to create media directory: ", err)
+			}
+		}
+
+		dest := filepath.Join(mediaDir, name)
+		if _, err := os.Stat(dest); err == nil {
+			continue
+		}
+
+		if copyZipFile(mf, dest) {
+			numAssets++
+		}
+	}
+
+	return numAssets
+}
+
+func copyZipFile(f *zip.File, dest string) bool {
+	rc, err := f.Open()
+	if err != nil {
+		log.Println("failed to open ", f.Name, ": ", err)
+		return false
+	}
+	defer rc.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, filePermission)
+	if err != nil {
+		log.Println("failed to create ", dest, ": ", err)
+		return false
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, rc)
+	if err != nil {
+		log.Println("failed to copy ", f.Name, " to ", dest, ": ", err)
+		return false
+	}
+
+	return true
+}
+
+// followingToUser synthesizes a twitter.User from an archive following
+// entry, using the same field names (id_str, …) as the friends API returns,
+// so that files written here are indistinguishable from API-sourced ones to
+// anything that reads following/*.json. The archive only carries the
+// account id and a profile link, so every other field is left zero-valued.
+func followingToUser(e archiveFollowing) twitter.User {
+	id, _ := strconv.ParseInt(e.Following.AccountID, 10, 64)
+	return twitter.User{
+		ID:    id,
+		IDStr: e.Following.AccountID,
+	}
+}
+
+func importFollowing(f *zip.File) {
+	var entries []archiveFollowing
+
+	err := readArchiveJSON(f, &entries)
+	if err != nil {
+		log.Fatal("failed to parse ", f.Name, ": ", err)
+	}
+
+	_ = os.Mkdir(pathFollowing, pathPermission)
+
+	var imported int
+	for _, e := range entries {
+		filename := filepath.Join(pathFollowing, e.Following.AccountID+".json")
+		if _, err := os.Stat(filename); err == nil {
+			continue
+		}
+
+		data, err := json.MarshalIndent(followingToUser(e), " ", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = os.WriteFile(filename, data, filePermission)
+		if err != nil {
+			log.Fatal("failed to write file: ", filename, " error: ", err)
+		}
+		imported++
+	}
+
+	fmt.Println("imported", imported, "followed accounts from archive")
+}
+
+func importDirectMessages(f *zip.File) {
+	var entries []archiveDirectMessage
+
+	err := readArchiveJSON(f, &entries)
+	if err != nil {
+		log.Fatal("failed to parse ", f.Name, ": ", err)
+	}
+
+	_ = os.Mkdir(pathDMs, pathPermission)
+
+	var imported int
+	for _, e := range entries {
+		filename := filepath.Join(pathDMs, e.DMConversation.ConversationID+".json")
+		if _, err := os.Stat(filename); err == nil {
+			continue
+		}
+
+		data, err := json.MarshalIndent(e.DMConversation, " ", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = os.WriteFile(filename, data, filePermission)
+		if err != nil {
+			log.Fatal("failed to write file: ", filename, " error: ", err)
+		}
+		imported++
+	}
+
+	fmt.Println("imported", imported, "DM conversations from archive")
+}
@@ -37,11 +37,33 @@ const (
 	pathFollowing  = "following"
 )
 
+// subcommands maps CLI subcommand names to their handlers, each of which
+// receives the remaining arguments after the subcommand itself.
+var subcommands = map[string]func([]string){
+	"import":    cmdImport,
+	"likes":     cmdLikes,
+	"home":      cmdHome,
+	"user":      cmdUser,
+	"mentions":  cmdMentions,
+	"following": cmdFollowing,
+	"all":       cmdAll,
+	"serve":     cmdServe,
+	"mastodon":  cmdMastodon,
+	"prune":     cmdPrune,
+}
+
 // simple backup tool to save the raw JSON objects for liked tweets and followed users to the file system,
 // as well as the media files for each tweet.
 // uses twitter v1.1 api, and needs auth credentials obtained from their dev portal for your account.
 func main() {
 
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+	}
+
 	var (
 		flags = flag.NewFlagSet("auth", flag.ExitOnError)
 
@@ -56,6 +78,8 @@ func main() {
 		consumerSecret = flags.String("consumer-secret", "", "Twitter Consumer Secret")
 		accessToken    = flags.String("access-token", "", "Twitter Access Token")
 		accessSecret   = flags.String("access-secret", "", "Twitter Access Secret")
+		full           = flags.Bool("full", false, "force a from-scratch walk, ignoring any saved state")
+		accounts       = flags.String("accounts", "", "path to a JSON config file listing multiple accounts to back up concurrently")
 	)
 
 	err := flags.Parse(os.Args[1:])
@@ -67,6 +91,11 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if *accounts != "" {
+		runMultiAccount(*accounts, *full)
+		return
+	}
+
 	if *consumerKey == "" ||
 		*consumerSecret == "" ||
 		*accessToken == "" ||
@@ -102,40 +131,50 @@ func main() {
 	}
 
 	fmt.Println("downloading likes")
-	downloadLikedTweets(client, pathLikes)
+	likes := downloadLikedTweets(client, pathLikes, *full)
 
 	fmt.Println("downloading followed users")
-	downloadFollowedUsers(client, pathFollowing)
+	following := downloadFollowedUsers(client, pathFollowing, *full)
+
+	fmt.Println("\nsummary: likes:", likes, "following:", following)
 }
 
-func downloadLikedTweets(client *twitter.Client, path string) {
+// downloadLikedTweets backs up the authenticated user's liked tweets.
+func downloadLikedTweets(client *twitter.Client, path string, full bool) int {
+	return backupTimeline(&favoritesSource{client: client}, path, full)
+}
+
+// backupTimeline pages backwards through source from the most recent tweet
+// down to the last one persisted by a previous run, saving each tweet and
+// its media under path the same way regardless of which endpoint source
+// pulls from.
+func backupTimeline(source Source, path string, full bool) int {
+
+	_ = os.Mkdir(path, pathPermission)
+
+	st, err := loadState(path)
+	if err != nil {
+		log.Fatal("failed to load state: ", err)
+	}
+	if full {
+		st = &state{}
+	}
 
 	var (
 		start = time.Now()
-		// Requests / 15-min window (app auth) = 75
-		delay = (15*60)/75*time.Second +
-
-			// add some extra to ensure we stay below the limit
-			500*time.Millisecond
+		delay = source.Delay()
 
-		yes         = true // who came up with the idea of using a bool pointer in the twitter api?? x)
-		lastID      int64
+		lastID      = st.MaxID
+		sinceFloor  = st.SinceID
+		newSinceID  = st.SinceID
 		total       int
 		numAssets   int
 		first, last = time.Now(), time.Time{}
+		reachedOld  bool
 	)
 
-	_ = os.RemoveAll(path)
-	_ = os.Mkdir(path, pathPermission)
-
 	for {
-		favListParams := &twitter.FavoriteListParams{
-			Count:           200,
-			TweetMode:       "extended",
-			IncludeEntities: &yes,
-			MaxID:           lastID,
-		}
-		tweets, resp, err := client.Favorites.List(favListParams)
+		tweets, resp, err := source.Fetch(lastID)
 		if err != nil {
 			log.Println(err)
 			break
@@ -146,6 +185,16 @@ func downloadLikedTweets(client *twitter.Client, path string) {
 				lastID = tweets[len(tweets)-1].ID
 				for _, t := range tweets {
 
+					// stop once we cross into tweets already persisted by a previous run
+					if t.ID <= sinceFloor {
+						reachedOld = true
+						break
+					}
+
+					if t.ID > newSinceID {
+						newSinceID = t.ID
+					}
+
 					ti := time.Unix(0, t.ID)
 					if ti.Before(first) {
 						first = ti
@@ -172,42 +221,26 @@ func downloadLikedTweets(client *twitter.Client, path string) {
 					}
 
 					// save media
-					if len(t.Entities.Media) > 0 {
-
-						mediaDir := filepath.Join(path, t.IDStr+"-media")
-						err = os.Mkdir(mediaDir, pathPermission)
-						if err != nil {
-							log.Fatal("failed to create media directory: ", err)
-						}
-
-						for _, m := range t.ExtendedEntities.Media {
-
-							//fmt.Println(" +", m.ExpandedURL, filepath.Base(m.MediaURL))
-
-							resp, err := http.Get(m.MediaURL)
-							if err != nil {
-								log.Fatal(err)
-							}
-							if resp.StatusCode == http.StatusOK {
-								data, err := ioutil.ReadAll(resp.Body)
-								if err != nil {
-									log.Fatal(err)
-								}
-								_ = resp.Body.Close()
-								err = os.WriteFile(filepath.Join(mediaDir, filepath.Base(m.MediaURL)), data, filePermission)
-								if err != nil {
-									log.Fatal(err)
-								}
-								numAssets++
-							} else {
-								fmt.Println(resp.Status, "skipping")
-							}
-						}
-					}
+					numAssets += downloadTweetMedia(t, path)
 				}
 
-				fmt.Println("+ downloaded", len(tweets), "tweets, total", total)
+				fmt.Println("["+source.Name()+"]", "+ downloaded", len(tweets), "tweets, total", total)
 				total += len(tweets)
+
+				// persist the resume cursor for this page, but leave SinceID
+				// (the termination threshold for this run) untouched until
+				// the walk completes, or later pages would compare against
+				// a high-water mark raised by this very run
+				st.MaxID = lastID
+				st.LastRun = time.Now()
+				if err := st.save(path); err != nil {
+					log.Fatal("failed to save state: ", err)
+				}
+
+				if reachedOld {
+					fmt.Println("caught up with previous backup")
+					break
+				}
 			} else {
 				fmt.Println("done")
 				break
@@ -221,6 +254,14 @@ func downloadLikedTweets(client *twitter.Client, path string) {
 		time.Sleep(delay)
 	}
 
+	// the full history has been walked end to end, reset the resume cursor
+	st.MaxID = 0
+	st.SinceID = newSinceID
+	st.LastRun = time.Now()
+	if err := st.save(path); err != nil {
+		log.Fatal("failed to save state: ", err)
+	}
+
 	size, err := directorySizeInBytes(path)
 	if err != nil {
 		log.Fatal(err)
@@ -228,9 +269,68 @@ func downloadLikedTweets(client *twitter.Client, path string) {
 
 	fmt.Println(total, "tweets and", numAssets, "media assets downloaded in", time.Since(start), "size on disk:", humanize.Bytes(uint64(size)))
 	fmt.Println("contains tweets liked between", first.Format("02/01/2006"), "and", last.Format("02/01/2006"))
+
+	return total
 }
 
-func downloadFollowedUsers(client *twitter.Client, path string) {
+// downloadTweetMedia fetches every media attachment of t (via
+// ExtendedEntities, to capture multi-photo tweets and multiple video
+// variants) into dir/<id>-media/, skipping files that already exist.
+func downloadTweetMedia(t twitter.Tweet, dir string) int {
+	if t.Entities == nil || len(t.Entities.Media) == 0 || t.ExtendedEntities == nil {
+		return 0
+	}
+
+	mediaDir := filepath.Join(dir, t.IDStr+"-media")
+	if _, err := os.Stat(mediaDir); err != nil {
+		if err := os.Mkdir(mediaDir, pathPermission); err != nil {
+			log.Fatal("failed to create media directory: ", err)
+		}
+	}
+
+	var numAssets int
+	for _, m := range t.ExtendedEntities.Media {
+		dest := filepath.Join(mediaDir, filepath.Base(m.MediaURL))
+		if _, err := os.Stat(dest); err == nil {
+			continue
+		}
+
+		resp, err := http.Get(m.MediaURL)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			data, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				log.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if err := os.WriteFile(dest, data, filePermission); err != nil {
+				log.Fatal(err)
+			}
+			numAssets++
+		} else {
+			_ = resp.Body.Close()
+			fmt.Println(resp.Status, "skipping", m.MediaURL)
+		}
+	}
+
+	return numAssets
+}
+
+func downloadFollowedUsers(client *twitter.Client, path string, full bool) int {
+
+	_ = os.Mkdir(path, pathPermission)
+
+	st, err := loadState(path)
+	if err != nil {
+		log.Fatal("failed to load state: ", err)
+	}
+	if full {
+		st = &state{}
+	}
 
 	var (
 		// Requests / 15-min window (app auth) = 15
@@ -243,11 +343,12 @@ func downloadFollowedUsers(client *twitter.Client, path string) {
 		total  int
 		yes          = true
 		cursor int64 = -1
-
-		_ = os.RemoveAll(path)
-		_ = os.Mkdir(path, pathPermission)
 	)
 
+	if st.Cursor != 0 {
+		cursor = st.Cursor
+	}
+
 	for {
 		favListParams := &twitter.FriendListParams{
 			Count:               200,
@@ -291,6 +392,13 @@ func downloadFollowedUsers(client *twitter.Client, path string) {
 			}
 
 			cursor = friends.NextCursor
+
+			st.Cursor = cursor
+			st.LastRun = time.Now()
+			if err := st.save(path); err != nil {
+				log.Fatal("failed to save state: ", err)
+			}
+
 			if cursor == 0 {
 				break
 			}
@@ -309,6 +417,8 @@ func downloadFollowedUsers(client *twitter.Client, path string) {
 	}
 
 	fmt.Println(total, "followed users downloaded in", time.Since(start), "size on disk:", humanize.Bytes(uint64(size)))
+
+	return total
 }
 
 func directorySizeInBytes(path string) (size int64, err error) {
@@ -0,0 +1,254 @@
+/*
+ * TWITTER-BACKUP
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+const pruneLogFile = "pruned.log"
+
+// allowlist holds tweet IDs and screen names that must never be pruned,
+// read from a flat file of one entry per line.
+type allowlist struct {
+	ids         map[string]bool
+	screenNames map[string]bool
+}
+
+func loadAllowlist(path string) (*allowlist, error) {
+	al := &allowlist{ids: map[string]bool{}, screenNames: map[string]bool{}}
+
+	if path == "" {
+		return al, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		if _, err := strconv.ParseInt(line, 10, 64); err == nil {
+			al.ids[line] = true
+		} else {
+			al.screenNames[line] = true
+		}
+	}
+
+	return al, scanner.Err()
+}
+
+func (al *allowlist) allowsTweet(t twitter.Tweet) bool {
+	if al.ids[t.IDStr] {
+		return true
+	}
+	if t.User != nil && al.screenNames[t.User.ScreenName] {
+		return true
+	}
+	return false
+}
+
+// cmdPrune deletes the authenticated user's own tweets and likes older
+// than --keep-days, provided a local archived copy already exists (it is
+// fetched first if missing), skipping anything matched by --allowlist.
+func cmdPrune(args []string) {
+	flags := flag.NewFlagSet("prune", flag.ExitOnError)
+	consumerKey, consumerSecret, accessToken, accessSecret, _, _ := credentialFlags(flags)
+	keepDays := flags.Int("keep-days", 30, "delete tweets/likes older than this many days")
+	allowlistPath := flags.String("allowlist", "", "path to a file listing tweet IDs and/or screen-names to never prune")
+	dryRun := flags.Bool("dry-run", false, "print what would be deleted without deleting anything")
+
+	client, verified := newClient(flags, args, consumerKey, consumerSecret, accessToken, accessSecret)
+
+	al, err := loadAllowlist(*allowlistPath)
+	if err != nil {
+		log.Fatal("failed to load allowlist: ", err)
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -*keepDays)
+
+	logFile, err := os.OpenFile(pruneLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, filePermission)
+	if err != nil {
+		log.Fatal("failed to open prune log: ", err)
+	}
+	defer logFile.Close()
+
+	userDir := "user-" + verified.ScreenName
+
+	fmt.Println("pruning tweets older than", cutoff.Format("02/01/2006"))
+	tweetsDeleted := pruneTweets(client, userDir, cutoff, al, *dryRun, logFile)
+
+	fmt.Println("pruning likes older than", cutoff.Format("02/01/2006"))
+	likesDeleted := pruneLikes(client, pathLikes, cutoff, al, *dryRun, logFile)
+
+	fmt.Println("\nsummary: tweets pruned:", tweetsDeleted, "likes pruned:", likesDeleted)
+}
+
+// pruneTweets walks the user's own archived tweets and deletes every one
+// older than cutoff via statuses/destroy, unless it is allowlisted.
+func pruneTweets(client *twitter.Client, dir string, cutoff time.Time, al *allowlist, dryRun bool, logFile *os.File) int {
+	_ = os.MkdirAll(dir, pathPermission)
+
+	var deleted int
+
+	source := &userTimelineSource{client: client, screenName: ""}
+	var maxID int64
+	for {
+		tweets, _, err := source.Fetch(maxID)
+		if err != nil {
+			log.Println(err)
+			break
+		}
+		if len(tweets) == 0 {
+			break
+		}
+		maxID = tweets[len(tweets)-1].ID - 1
+
+		for _, t := range tweets {
+			ti, err := t.CreatedAtTime()
+			if err != nil || ti.After(cutoff) {
+				continue
+			}
+
+			if al.allowsTweet(t) {
+				continue
+			}
+
+			filename, err := ensureArchived(t, dir)
+			if err != nil {
+				log.Println("failed to archive tweet", t.IDStr, "before pruning:", err)
+				continue
+			}
+
+			if dryRun {
+				fmt.Println("[dry-run] would delete tweet", t.IDStr)
+				continue
+			}
+
+			_, _, err = client.Statuses.Destroy(t.ID, nil)
+			if err != nil {
+				log.Println("failed to delete tweet", t.IDStr, ":", err)
+				continue
+			}
+
+			logPrune(logFile, t.IDStr, filename)
+			deleted++
+		}
+
+		fmt.Println("sleeping for", source.Delay())
+		time.Sleep(source.Delay())
+	}
+
+	return deleted
+}
+
+// pruneLikes walks the user's archived likes and un-favorites every one
+// older than cutoff, unless the liked tweet's author is allowlisted.
+func pruneLikes(client *twitter.Client, dir string, cutoff time.Time, al *allowlist, dryRun bool, logFile *os.File) int {
+	_ = os.MkdirAll(dir, pathPermission)
+
+	var deleted int
+
+	source := &favoritesSource{client: client}
+	var maxID int64
+	for {
+		tweets, _, err := source.Fetch(maxID)
+		if err != nil {
+			log.Println(err)
+			break
+		}
+		if len(tweets) == 0 {
+			break
+		}
+		maxID = tweets[len(tweets)-1].ID - 1
+
+		for _, t := range tweets {
+			ti, err := t.CreatedAtTime()
+			if err != nil || ti.After(cutoff) {
+				continue
+			}
+
+			if al.allowsTweet(t) {
+				continue
+			}
+
+			filename, err := ensureArchived(t, dir)
+			if err != nil {
+				log.Println("failed to archive like", t.IDStr, "before pruning:", err)
+				continue
+			}
+
+			if dryRun {
+				fmt.Println("[dry-run] would un-favorite tweet", t.IDStr)
+				continue
+			}
+
+			_, _, err = client.Favorites.Destroy(&twitter.FavoriteDestroyParams{ID: t.ID})
+			if err != nil {
+				log.Println("failed to un-favorite tweet", t.IDStr, ":", err)
+				continue
+			}
+
+			logPrune(logFile, t.IDStr, filename)
+			deleted++
+		}
+
+		fmt.Println("sleeping for", source.Delay())
+		time.Sleep(source.Delay())
+	}
+
+	return deleted
+}
+
+// ensureArchived makes sure a local JSON+media copy of t exists under dir
+// before it gets deleted remotely, fetching whichever parts are missing,
+// and returns the JSON file's path.
+func ensureArchived(t twitter.Tweet, dir string) (string, error) {
+	filename := filepath.Join(dir, t.IDStr+".json")
+
+	if _, err := os.Stat(filename); err != nil {
+		data, err := json.MarshalIndent(t, " ", "  ")
+		if err != nil {
+			return "", err
+		}
+
+		if err := os.WriteFile(filename, data, filePermission); err != nil {
+			return "", err
+		}
+	}
+
+	downloadTweetMedia(t, dir)
+
+	return filename, nil
+}
+
+func logPrune(logFile *os.File, id, localPath string) {
+	fmt.Fprintf(logFile, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), id, localPath)
+}
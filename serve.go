@@ -0,0 +1,369 @@
+/*
+ * TWITTER-BACKUP
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+	_ "modernc.org/sqlite"
+)
+
+// timelineDirs lists the directories scanned into the SQLite index besides
+// pathLikes and pathFollowing. user-<screen-name> directories are
+// discovered at scan time via a glob, since the screen name varies.
+var timelineDirs = []string{"home", "mentions"}
+
+// cmdServe scans the on-disk backup directories into a SQLite index and
+// serves a small browsable frontend over it.
+func cmdServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":8080", "address to serve the web UI on")
+	dbPath := flags.String("db", "twitter-backup.db", "path to the SQLite index database")
+
+	if err := flags.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := openIndex(*dbPath)
+	if err != nil {
+		log.Fatal("failed to open index: ", err)
+	}
+	defer db.Close()
+
+	dirs := append([]string{pathLikes}, timelineDirs...)
+	matches, _ := filepath.Glob("user-*")
+	dirs = append(dirs, matches...)
+
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		n, err := scanTweetDir(db, dir)
+		if err != nil {
+			log.Println("failed to scan", dir, ":", err)
+			continue
+		}
+		fmt.Println("indexed", n, "tweets from", dir)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/tweets", apiTweetsHandler(db))
+	mux.HandleFunc("/api/media/", apiMediaHandler(db))
+	mux.HandleFunc("/", frontendHandler)
+
+	fmt.Println("serving on", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// openIndex opens (and if necessary creates) the SQLite index database.
+func openIndex(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS tweets (
+			id INTEGER PRIMARY KEY,
+			created_at TEXT,
+			screen_name TEXT,
+			full_text TEXT,
+			media_paths TEXT,
+			in_reply_to_id TEXT,
+			retweet_of_id TEXT,
+			source_dir TEXT
+		)`,
+		`CREATE VIRTUAL TABLE IF NOT EXISTS tweets_fts USING fts5(
+			id UNINDEXED, full_text
+		)`,
+		`CREATE TABLE IF NOT EXISTS scanned_files (
+			path TEXT PRIMARY KEY,
+			mtime INTEGER
+		)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+// scanTweetDir indexes every <id>.json file in dir that hasn't changed
+// since the last scan, based on its modification time.
+func scanTweetDir(db *sql.DB, dir string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var indexed int
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") || entry.Name() == stateFileName {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+
+		info, err := entry.Info()
+		if err != nil {
+			return indexed, err
+		}
+
+		var lastMtime int64
+		row := db.QueryRow(`SELECT mtime FROM scanned_files WHERE path = ?`, path)
+		_ = row.Scan(&lastMtime)
+
+		if info.ModTime().Unix() == lastMtime {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return indexed, err
+		}
+
+		var t twitter.Tweet
+		if err := json.Unmarshal(data, &t); err != nil || t.IDStr == "" {
+			continue
+		}
+
+		mediaDir := filepath.Join(dir, t.IDStr+"-media")
+		var mediaPaths []string
+		if files, err := os.ReadDir(mediaDir); err == nil {
+			for _, f := range files {
+				mediaPaths = append(mediaPaths, f.Name())
+			}
+		}
+
+		screenName := ""
+		if t.User != nil {
+			screenName = t.User.ScreenName
+		}
+
+		if err := indexTweet(db, t, screenName, strings.Join(mediaPaths, ","), dir); err != nil {
+			return indexed, err
+		}
+
+		_, err = db.Exec(`INSERT INTO scanned_files (path, mtime) VALUES (?, ?)
+			ON CONFLICT(path) DO UPDATE SET mtime = excluded.mtime`, path, info.ModTime().Unix())
+		if err != nil {
+			return indexed, err
+		}
+
+		indexed++
+	}
+
+	return indexed, nil
+}
+
+func indexTweet(db *sql.DB, t twitter.Tweet, screenName, mediaPaths, sourceDir string) error {
+	retweetOfID := ""
+	if t.RetweetedStatus != nil {
+		retweetOfID = t.RetweetedStatus.IDStr
+	}
+
+	_, err := db.Exec(`INSERT INTO tweets
+			(id, created_at, screen_name, full_text, media_paths, in_reply_to_id, retweet_of_id, source_dir)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			created_at = excluded.created_at,
+			screen_name = excluded.screen_name,
+			full_text = excluded.full_text,
+			media_paths = excluded.media_paths,
+			in_reply_to_id = excluded.in_reply_to_id,
+			retweet_of_id = excluded.retweet_of_id,
+			source_dir = excluded.source_dir`,
+		t.ID, t.CreatedAt, screenName, t.FullText, mediaPaths, t.InReplyToStatusIDStr, retweetOfID, sourceDir)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`DELETE FROM tweets_fts WHERE id = ?`, t.IDStr)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`INSERT INTO tweets_fts (id, full_text) VALUES (?, ?)`, t.IDStr, t.FullText)
+	return err
+}
+
+// apiTweet is the JSON shape returned by /api/tweets.
+type apiTweet struct {
+	ID          string `json:"id"`
+	CreatedAt   string `json:"created_at"`
+	ScreenName  string `json:"screen_name"`
+	FullText    string `json:"full_text"`
+	MediaPaths  string `json:"media_paths"`
+	InReplyToID string `json:"in_reply_to_id"`
+	RetweetOfID string `json:"retweet_of_id"`
+	SourceDir   string `json:"source_dir"`
+}
+
+// apiTweetsHandler serves GET /api/tweets?before=<id>&user=<screen>&q=<fts>,
+// returning a page of tweets ordered newest first.
+func apiTweetsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			// before == 0 means "no cursor", since real tweet IDs are always > 0
+			before, _ = strconv.ParseInt(r.URL.Query().Get("before"), 10, 64)
+			user      = r.URL.Query().Get("user")
+			q         = r.URL.Query().Get("q")
+
+			rows *sql.Rows
+			err  error
+		)
+
+		switch {
+		case q != "":
+			rows, err = db.Query(`
+				SELECT t.id, t.created_at, t.screen_name, t.full_text, t.media_paths, t.in_reply_to_id, t.retweet_of_id, t.source_dir
+				FROM tweets t JOIN tweets_fts f ON CAST(f.id AS INTEGER) = t.id
+				WHERE tweets_fts MATCH ? AND (? = 0 OR t.id < ?) AND (? = '' OR t.screen_name = ?)
+				ORDER BY t.id DESC LIMIT 50`, q, before, before, user, user)
+		case user != "":
+			rows, err = db.Query(`
+				SELECT id, created_at, screen_name, full_text, media_paths, in_reply_to_id, retweet_of_id, source_dir
+				FROM tweets WHERE screen_name = ? AND (? = 0 OR id < ?)
+				ORDER BY id DESC LIMIT 50`, user, before, before)
+		default:
+			rows, err = db.Query(`
+				SELECT id, created_at, screen_name, full_text, media_paths, in_reply_to_id, retweet_of_id, source_dir
+				FROM tweets WHERE (? = 0 OR id < ?)
+				ORDER BY id DESC LIMIT 50`, before, before)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var tweets []apiTweet
+		for rows.Next() {
+			var (
+				t  apiTweet
+				id int64
+			)
+			if err := rows.Scan(&id, &t.CreatedAt, &t.ScreenName, &t.FullText, &t.MediaPaths, &t.InReplyToID, &t.RetweetOfID, &t.SourceDir); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			// keep the id as a string in the JSON response: snowflake IDs
+			// exceed JavaScript's safe integer range and would lose precision
+			t.ID = strconv.FormatInt(id, 10)
+			tweets = append(tweets, t)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(tweets)
+	}
+}
+
+// apiMediaHandler serves GET /api/media/<id>/<file>, resolving the id back
+// to the directory it was indexed from.
+func apiMediaHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/api/media/"), "/", 2)
+		if len(parts) != 2 {
+			http.NotFound(w, r)
+			return
+		}
+		id, file := parts[0], parts[1]
+
+		if strings.Contains(file, "..") || strings.Contains(id, "..") {
+			http.Error(w, "invalid path", http.StatusBadRequest)
+			return
+		}
+
+		var sourceDir string
+		err := db.QueryRow(`SELECT source_dir FROM tweets WHERE id = ?`, id).Scan(&sourceDir)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		http.ServeFile(w, r, filepath.Join(sourceDir, id+"-media", file))
+	}
+}
+
+const frontendHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>twitter-backup</title>
+<style>
+body { font-family: sans-serif; max-width: 640px; margin: 2em auto; background: #15202b; color: #eee; }
+input { width: 100%; padding: 0.5em; margin-bottom: 1em; }
+.tweet { border-bottom: 1px solid #38444d; padding: 1em 0; }
+.tweet .meta { color: #8899a6; font-size: 0.85em; }
+.tweet img, .tweet video { max-width: 100%; border-radius: 8px; margin-top: 0.5em; }
+</style>
+</head>
+<body>
+<h1>twitter-backup</h1>
+<input id="q" placeholder="search archived tweets…">
+<div id="timeline"></div>
+<script>
+const timeline = document.getElementById('timeline');
+const q = document.getElementById('q');
+
+async function load() {
+	const params = new URLSearchParams();
+	if (q.value) params.set('q', q.value);
+	const res = await fetch('/api/tweets?' + params.toString());
+	const tweets = await res.json();
+	timeline.innerHTML = '';
+	for (const t of tweets || []) {
+		const el = document.createElement('div');
+		el.className = 'tweet';
+		let media = '';
+		if (t.media_paths) {
+			for (const f of t.media_paths.split(',')) {
+				if (!f) continue;
+				const src = '/api/media/' + t.id + '/' + f;
+				media += /\.(mp4|mov|m4v)$/i.test(f)
+					? '<video src="' + src + '" controls></video>'
+					: '<img src="' + src + '" loading="lazy">';
+			}
+		}
+		el.innerHTML = '<div class="meta">@' + (t.screen_name || '') + ' · ' + (t.created_at || '') + '</div>' +
+			'<div>' + (t.full_text || '').replace(/</g, '&lt;') + '</div>' + media;
+		timeline.appendChild(el);
+	}
+}
+
+q.addEventListener('input', () => load());
+load();
+</script>
+</body>
+</html>`
+
+func frontendHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(frontendHTML))
+}
@@ -0,0 +1,125 @@
+/*
+ * TWITTER-BACKUP
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Source abstracts a paginated Twitter endpoint that yields tweets, so that
+// backupTimeline can archive favorites, timelines and mentions the same way.
+type Source interface {
+	// Fetch returns the next page of tweets older than maxID (maxID == 0
+	// fetches the most recent page).
+	Fetch(maxID int64) ([]twitter.Tweet, *http.Response, error)
+
+	// Delay is the pause between requests required to stay within this
+	// endpoint's 15-minute rate-limit window.
+	Delay() time.Duration
+
+	// Name identifies the source, used for logging and as the default
+	// backup directory name.
+	Name() string
+}
+
+var includeEntities = true
+
+// favoritesSource backs up the authenticated user's liked tweets.
+// Requests / 15-min window (app auth) = 75.
+type favoritesSource struct {
+	client *twitter.Client
+}
+
+func (s *favoritesSource) Fetch(maxID int64) ([]twitter.Tweet, *http.Response, error) {
+	return s.client.Favorites.List(&twitter.FavoriteListParams{
+		Count:           200,
+		TweetMode:       "extended",
+		IncludeEntities: &includeEntities,
+		MaxID:           maxID,
+	})
+}
+
+func (s *favoritesSource) Delay() time.Duration {
+	return (15*60)/75*time.Second + 500*time.Millisecond
+}
+
+func (s *favoritesSource) Name() string { return "likes" }
+
+// homeTimelineSource backs up the authenticated user's home timeline.
+// Requests / 15-min window (user auth) = 15.
+type homeTimelineSource struct {
+	client *twitter.Client
+}
+
+func (s *homeTimelineSource) Fetch(maxID int64) ([]twitter.Tweet, *http.Response, error) {
+	return s.client.Timelines.HomeTimeline(&twitter.HomeTimelineParams{
+		Count:           200,
+		TweetMode:       "extended",
+		IncludeEntities: &includeEntities,
+		MaxID:           maxID,
+	})
+}
+
+func (s *homeTimelineSource) Delay() time.Duration {
+	return (15*60)/15*time.Second + 500*time.Millisecond
+}
+
+func (s *homeTimelineSource) Name() string { return "home" }
+
+// userTimelineSource backs up the tweets posted by a single account, the
+// authenticated user's own by default.
+// Requests / 15-min window (user auth) = 900.
+type userTimelineSource struct {
+	client     *twitter.Client
+	screenName string
+}
+
+func (s *userTimelineSource) Fetch(maxID int64) ([]twitter.Tweet, *http.Response, error) {
+	return s.client.Timelines.UserTimeline(&twitter.UserTimelineParams{
+		ScreenName: s.screenName,
+		Count:      200,
+		TweetMode:  "extended",
+		MaxID:      maxID,
+	})
+}
+
+func (s *userTimelineSource) Delay() time.Duration {
+	return (15*60)/900*time.Second + 500*time.Millisecond
+}
+
+func (s *userTimelineSource) Name() string { return "user" }
+
+// mentionTimelineSource backs up tweets mentioning the authenticated user.
+// Requests / 15-min window (user auth) = 75.
+type mentionTimelineSource struct {
+	client *twitter.Client
+}
+
+func (s *mentionTimelineSource) Fetch(maxID int64) ([]twitter.Tweet, *http.Response, error) {
+	return s.client.Timelines.MentionTimeline(&twitter.MentionTimelineParams{
+		Count:           200,
+		TweetMode:       "extended",
+		IncludeEntities: &includeEntities,
+		MaxID:           maxID,
+	})
+}
+
+func (s *mentionTimelineSource) Delay() time.Duration {
+	return (15*60)/75*time.Second + 500*time.Millisecond
+}
+
+func (s *mentionTimelineSource) Name() string { return "mentions" }
@@ -0,0 +1,76 @@
+/*
+ * TWITTER-BACKUP
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const stateFileName = "state.json"
+
+// state tracks incremental backup progress for a single endpoint directory,
+// so subsequent runs can resume instead of re-walking the full history.
+type state struct {
+	// SinceID is the newest tweet/user ID already persisted to disk; the
+	// next run stops paginating once it crosses this ID.
+	SinceID int64 `json:"since_id"`
+
+	// MaxID is the oldest ID reached by an interrupted run, used to resume
+	// pagination backwards in time without starting over.
+	MaxID int64 `json:"max_id"`
+
+	// Cursor holds the friend/follower list cursor of an interrupted run.
+	Cursor int64 `json:"cursor"`
+
+	// LastRun is the timestamp of the last successfully completed page.
+	LastRun time.Time `json:"last_run"`
+}
+
+// loadState reads state.json from dir. A missing file is not an error and
+// yields a zero-value state, so first runs behave like a from-scratch walk.
+func loadState(dir string) (*state, error) {
+	data, err := os.ReadFile(filepath.Join(dir, stateFileName))
+	if os.IsNotExist(err) {
+		return &state{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, err
+	}
+
+	return &st, nil
+}
+
+// save persists the state atomically: it writes to a temp file in dir and
+// renames it into place, so a crash mid-write can never corrupt state.json.
+func (st *state) save(dir string) error {
+	data, err := json.MarshalIndent(st, " ", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(dir, stateFileName+".tmp")
+	if err := os.WriteFile(tmp, data, filePermission); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Join(dir, stateFileName))
+}